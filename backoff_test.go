@@ -0,0 +1,134 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package docappender_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2"
+	"github.com/elastic/go-docappender/v2/docappendertest"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := docappender.ExponentialBackoff(time.Second, 10*time.Second)
+
+	delay := backoff(1)
+	require.GreaterOrEqual(t, delay, time.Second)
+	require.Less(t, delay, time.Second+time.Second/2)
+
+	delay = backoff(4) // 8s, still under the 10s cap
+	require.GreaterOrEqual(t, delay, 8*time.Second)
+	require.Less(t, delay, 8*time.Second+8*time.Second/2)
+
+	delay = backoff(10) // would overflow well past max
+	require.GreaterOrEqual(t, delay, 10*time.Second)
+	require.Less(t, delay, 10*time.Second+5*time.Second)
+}
+
+func TestConstantBackoff(t *testing.T) {
+	backoff := docappender.ConstantBackoff(time.Second)
+	for attempt := 1; attempt <= 3; attempt++ {
+		delay := backoff(attempt)
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.Less(t, delay, time.Second+time.Second/2)
+	}
+}
+
+func TestBulkIndexerRetryBackoffDefersRetries(t *testing.T) {
+	var requests int
+	client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, result := docappendertest.DecodeBulkRequest(r)
+		for _, itemsMap := range result.Items {
+			for k, item := range itemsMap {
+				result.HasErrors = true
+				item.Status = http.StatusTooManyRequests
+				itemsMap[k] = item
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+	indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{
+		Client:                client,
+		MaxDocumentRetries:    10,
+		RetryOnDocumentStatus: []int{http.StatusTooManyRequests},
+		RetryBackoff:          docappender.ConstantBackoff(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(docappender.BulkIndexerItem{
+		Index: "testidx",
+		Body:  newJSONReader(map[string]any{"@timestamp": time.Now().Format(docappendertest.TimestampFormat)}),
+	}))
+
+	stat, err := indexer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stat.RetriedDocs)
+	require.Equal(t, 1, len(stat.FailedDocs))
+	require.Equal(t, int64(0), stat.PermanentlyDroppedDocs)
+	require.Equal(t, 1, requests)
+
+	// The backoff delay is an hour, so the item should not be eligible
+	// for resending yet, and no further request should be made.
+	stat, err = indexer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stat.DeferredDocs)
+	require.Equal(t, 1, requests)
+	require.Equal(t, 1, indexer.Items())
+}
+
+func TestBulkIndexerRetryAfterOverridesBackoff(t *testing.T) {
+	client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, result := docappendertest.DecodeBulkRequest(r)
+		for _, itemsMap := range result.Items {
+			for k, item := range itemsMap {
+				result.HasErrors = true
+				item.Status = http.StatusTooManyRequests
+				itemsMap[k] = item
+			}
+		}
+		w.Header().Set("Retry-After", "0")
+		json.NewEncoder(w).Encode(result)
+	})
+	indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{
+		Client:                client,
+		MaxDocumentRetries:    10,
+		RetryOnDocumentStatus: []int{http.StatusTooManyRequests},
+		RetryBackoff:          docappender.ConstantBackoff(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(docappender.BulkIndexerItem{
+		Index: "testidx",
+		Body:  newJSONReader(map[string]any{"@timestamp": time.Now().Format(docappendertest.TimestampFormat)}),
+	}))
+
+	_, err = indexer.Flush(context.Background())
+	require.NoError(t, err)
+
+	// Retry-After: 0 overrides the hour-long configured backoff, so the
+	// item should be immediately eligible for resending.
+	stat, err := indexer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stat.DeferredDocs)
+	require.Equal(t, int64(1), stat.RetriedDocs)
+}
@@ -0,0 +1,721 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package docappender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/elastic/go-docappender/v2/esclient"
+)
+
+// Transport is the interface required of Elasticsearch clients used by
+// BulkIndexer. It is satisfied by *elastictransport.Client, among others.
+type Transport interface {
+	Perform(*http.Request) (*http.Response, error)
+}
+
+// BulkIndexerConfig holds the configuration for creating a BulkIndexer.
+type BulkIndexerConfig struct {
+	// Client holds the Elasticsearch client used to flush the bulk request.
+	Client Transport
+
+	// MaxDocumentRetries holds the maximum number of times a single
+	// document may be retried, after a response with a retryable status
+	// is observed for it.
+	MaxDocumentRetries int
+
+	// RetryOnDocumentStatus holds the set of HTTP status codes for which
+	// a document should be retried, rather than reported as failed.
+	RetryOnDocumentStatus []int
+
+	// RetryBackoff returns the amount of time to wait before a document
+	// is eligible to be resent, given the number of attempts already
+	// made for that document (starting at 1 for the first retry).
+	//
+	// If RetryBackoff is nil, documents are retried on the very next
+	// Flush call with no delay.
+	//
+	// In either case, a Retry-After header (in seconds) on the bulk
+	// response overrides the delay for every document retried from it.
+	RetryBackoff func(attempt int) time.Duration
+
+	// CompressionLevel holds the gzip compression level to use when
+	// encoding the bulk request body, as defined by compress/flate.
+	//
+	// CompressionLevel is ignored if Compressor is set. It is kept as a
+	// shorthand for constructing a GzipCompressor, for backwards
+	// compatibility.
+	CompressionLevel int
+
+	// Compressor holds the codec used to compress the bulk request
+	// body. If nil, a GzipCompressor using CompressionLevel is used.
+	Compressor Compressor
+
+	// CaptureFullErrorReason, if set, causes the full error.reason
+	// returned by Elasticsearch to be recorded on FailedDocs for
+	// permanently dropped documents, rather than the redacted prefix up
+	// to the first ". " separator. Documents that are being retried
+	// always keep the full error.reason.
+	CaptureFullErrorReason bool
+
+	// FailureSink, if set, is invoked for every document that
+	// BulkIndexer permanently gives up on, either because it exhausted
+	// MaxDocumentRetries or because Elasticsearch reported a
+	// non-retryable status for it. It is called synchronously from
+	// Flush, before the document is dropped from the buffer.
+	FailureSink FailureSink
+
+	// ClusterInfoProvider, if set, is used to ping the cluster on the
+	// first call to Flush and negotiate the esclient.Capabilities used
+	// to render bulk action lines, e.g. whether "_type" must be
+	// included for Elasticsearch 6.x. If nil, BulkIndexer assumes the
+	// capabilities of the latest supported Elasticsearch version.
+	ClusterInfoProvider esclient.ClusterInfoProvider
+
+	// BeforeFlush, if set, is called synchronously at the start of
+	// Flush with the documents about to be sent, before the request is
+	// encoded.
+	BeforeFlush func(ctx context.Context, items []BulkIndexerItem)
+
+	// AfterFlush, if set, is called synchronously before Flush returns,
+	// with information about the request that was sent (if any) and
+	// the response received, or the error that caused Flush to return
+	// early. It is called exactly once per Flush call that reaches the
+	// point of attempting to send a request.
+	AfterFlush func(ctx context.Context, req BulkRequestInfo, resp BulkResponseInfo, err error)
+
+	// OnItemResult, if set, is called synchronously for every item in
+	// the request, once its outcome (success, retry or failure) is
+	// known, before Flush returns.
+	OnItemResult func(ctx context.Context, item BulkIndexerItem, resp BulkIndexerResponseItem)
+}
+
+// BulkRequestInfo describes a single bulk request sent by Flush, for
+// use by BulkIndexerConfig.AfterFlush.
+type BulkRequestInfo struct {
+	// UncompressedSize holds the uncompressed size, in bytes, of the
+	// request body.
+	UncompressedSize int
+
+	// CompressedSize holds the size, in bytes, of the request body as
+	// sent over the wire.
+	CompressedSize int
+
+	// ItemCount holds the number of documents included in the request.
+	ItemCount int
+
+	// Attempt holds the number of requests BulkIndexer has sent so
+	// far, including this one, starting at 1.
+	Attempt int
+}
+
+// BulkResponseInfo describes the response to a single bulk request, for
+// use by BulkIndexerConfig.AfterFlush. It is the zero value if Flush
+// returned before a response was received.
+type BulkResponseInfo struct {
+	// StatusCode holds the HTTP status code of the response.
+	StatusCode int
+
+	// TookMillis holds the "took" field of the bulk response, in
+	// milliseconds.
+	TookMillis int64
+
+	// StatusCounts maps each per-item status code seen in the response
+	// to the number of items that reported it.
+	StatusCounts map[int]int
+}
+
+// FailureSink receives documents that BulkIndexer has permanently given
+// up on. Implementations can use this to persist or forward documents
+// that would otherwise be silently dropped; see the docappender/dlq
+// subpackage for ready-made sinks.
+type FailureSink interface {
+	// OnDocumentFailed is invoked once per permanently failed document,
+	// with the response item describing why it failed. Returning an
+	// error does not prevent the document from being dropped; the
+	// error is instead joined into the error returned by Flush.
+	OnDocumentFailed(ctx context.Context, item BulkIndexerItem, lastResponse BulkIndexerResponseItem) error
+}
+
+// ExponentialBackoff returns a RetryBackoff policy that doubles the delay
+// on each attempt, starting at initial and capped at max, with uniform
+// jitter in [0, delay/2) added to avoid retry storms against the cluster.
+func ExponentialBackoff(initial, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		if attempt < 1 {
+			attempt = 1
+		}
+		delay := initial * time.Duration(uint64(1)<<uint(attempt-1))
+		if delay <= 0 || delay > max {
+			delay = max
+		}
+		return withJitter(delay)
+	}
+}
+
+// ConstantBackoff returns a RetryBackoff policy that always waits delay,
+// plus uniform jitter in [0, delay/2), between retries.
+func ConstantBackoff(delay time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		return withJitter(delay)
+	}
+}
+
+func withJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	jitter := delay / 2
+	if jitter <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Int63n(int64(jitter)))
+}
+
+// Compressor abstracts the codec used to compress a BulkIndexer's
+// request bodies, so that alternatives to gzip (e.g. zstd) can be
+// plugged in via BulkIndexerConfig.Compressor.
+type Compressor interface {
+	// NewWriter returns a writer that compresses writes to w. Later
+	// calls to Reset redirect this same writer's output.
+	NewWriter(w io.Writer) io.WriteCloser
+
+	// ContentEncoding returns the value to use for the request's
+	// Content-Encoding header, or "" if the body is not encoded.
+	ContentEncoding() string
+
+	// Reset redirects the output of the writer most recently returned
+	// by NewWriter to w, so that it can be reused across Flush calls.
+	Reset(w io.Writer)
+}
+
+// GzipCompressor is a Compressor that encodes request bodies using
+// compress/gzip (via klauspost/compress/gzip) at Level, as defined by
+// compress/flate.
+type GzipCompressor struct {
+	Level int
+
+	w *gzip.Writer
+}
+
+// NewWriter implements Compressor.
+func (c *GzipCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	gz, err := gzip.NewWriterLevel(w, c.Level)
+	if err != nil {
+		// c.Level is invalid; fall back to the default level rather
+		// than plumbing an error through Compressor.NewWriter.
+		gz = gzip.NewWriter(w)
+	}
+	c.w = gz
+	return gz
+}
+
+// ContentEncoding implements Compressor.
+func (c *GzipCompressor) ContentEncoding() string { return "gzip" }
+
+// Reset implements Compressor.
+func (c *GzipCompressor) Reset(w io.Writer) { c.w.Reset(w) }
+
+// NoopCompressor is a Compressor that performs no compression.
+type NoopCompressor struct {
+	w io.Writer
+}
+
+// NewWriter implements Compressor.
+func (c *NoopCompressor) NewWriter(w io.Writer) io.WriteCloser {
+	c.w = w
+	return c
+}
+
+// ContentEncoding implements Compressor.
+func (c *NoopCompressor) ContentEncoding() string { return "" }
+
+// Reset implements Compressor.
+func (c *NoopCompressor) Reset(w io.Writer) { c.w = w }
+
+// Write implements io.Writer.
+func (c *NoopCompressor) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+// Close implements io.Closer.
+func (c *NoopCompressor) Close() error { return nil }
+
+// BulkIndexerItem represents a single document to be indexed in a bulk
+// request.
+type BulkIndexerItem struct {
+	// Index holds the name of the index (or data stream) the document
+	// should be indexed into.
+	Index string
+
+	// DocumentID, if non-empty, holds the _id to use for the document.
+	DocumentID string
+
+	// RequireAlias, if set, is rendered as "require_alias" in the
+	// action line. It is silently dropped against clusters whose
+	// negotiated esclient.Capabilities don't support it.
+	RequireAlias bool
+
+	// DynamicTemplates, if non-empty, is rendered as "dynamic_templates"
+	// in the action line. It is silently dropped against clusters whose
+	// negotiated esclient.Capabilities don't support it.
+	DynamicTemplates map[string]string
+
+	// Body holds the encoded document body.
+	Body io.WriterTo
+}
+
+// BulkIndexerResponseItem represents the result of indexing a single
+// document, as reported by Elasticsearch's bulk API.
+type BulkIndexerResponseItem struct {
+	Index  string `json:"_index"`
+	Status int    `json:"status"`
+	Error  struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error,omitempty"`
+}
+
+// BulkIndexerResponseStat holds the result of a single call to
+// BulkIndexer.Flush.
+type BulkIndexerResponseStat struct {
+	// Indexed holds the number of documents successfully indexed.
+	Indexed int64
+
+	// RetriedDocs holds the number of documents that were re-enqueued
+	// for a future Flush call, having failed with a retryable status.
+	RetriedDocs int64
+
+	// DeferredDocs holds the number of buffered documents that were
+	// not included in this Flush call because their RetryBackoff delay
+	// had not yet elapsed.
+	DeferredDocs int64
+
+	// FailedDocs holds every document sent in this Flush call's request
+	// that was not indexed, whether it is being retried in a future
+	// Flush call or has been permanently dropped (see
+	// PermanentlyDroppedDocs). Deferred documents are not included.
+	FailedDocs []BulkIndexerResponseItem
+
+	// PermanentlyDroppedDocs holds the number of documents in FailedDocs
+	// that BulkIndexer has given up on, either because they returned a
+	// non-retryable status or because they exhausted
+	// MaxDocumentRetries. These are the documents passed to
+	// BulkIndexerConfig.FailureSink.
+	PermanentlyDroppedDocs int64
+}
+
+// bufferedItem holds a BulkIndexerItem along with the encoded request
+// bytes and retry bookkeeping needed to resend it.
+type bufferedItem struct {
+	item BulkIndexerItem
+
+	action   []byte
+	document []byte
+
+	attempts    int
+	nextAttempt time.Time
+}
+
+func (b *bufferedItem) size() int {
+	return len(b.action) + len(b.document)
+}
+
+// wireAction returns the action line to send for this item, rendered
+// for the given capabilities. If caps.RequiresType (ES 6.x) or the item
+// uses a field caps doesn't support (e.g. "require_alias" against
+// Elasticsearch Serverless), this rebuilds the action line accordingly;
+// otherwise the action line computed in Add is reused as-is.
+func (b *bufferedItem) wireAction(caps esclient.Capabilities) []byte {
+	dropRequireAlias := b.item.RequireAlias && !caps.SupportsRequireAlias
+	dropDynamicTemplates := len(b.item.DynamicTemplates) > 0 && !caps.SupportsDynamicTemplates
+	if !caps.RequiresType && !dropRequireAlias && !dropDynamicTemplates {
+		return b.action
+	}
+	meta := map[string]any{"_index": b.item.Index}
+	if caps.RequiresType {
+		meta["_type"] = "_doc"
+	}
+	if b.item.DocumentID != "" {
+		meta["_id"] = b.item.DocumentID
+	}
+	if b.item.RequireAlias && !dropRequireAlias {
+		meta["require_alias"] = true
+	}
+	if len(b.item.DynamicTemplates) > 0 && !dropDynamicTemplates {
+		meta["dynamic_templates"] = b.item.DynamicTemplates
+	}
+	action, err := json.Marshal(map[string]any{"create": meta})
+	if err != nil {
+		// meta contains only strings and bools; marshaling cannot fail.
+		return b.action
+	}
+	return append(action, '\n')
+}
+
+// discardedItem reconstructs a BulkIndexerItem for a document that is
+// about to be permanently dropped, so that it can be handed to a
+// FailureSink. The returned item's Body re-reads the document bytes
+// that were originally encoded in Add.
+func (b *bufferedItem) discardedItem() BulkIndexerItem {
+	doc := b.document
+	doc = doc[:len(doc)-1] // strip the trailing newline added in Add
+	return BulkIndexerItem{
+		Index:            b.item.Index,
+		DocumentID:       b.item.DocumentID,
+		RequireAlias:     b.item.RequireAlias,
+		DynamicTemplates: b.item.DynamicTemplates,
+		Body:             bytes.NewReader(doc),
+	}
+}
+
+// BulkIndexer accumulates documents in memory, encoding them as they are
+// added, and compresses and flushes them to Elasticsearch's bulk API in
+// batches.
+//
+// BulkIndexer is not safe for concurrent use.
+type BulkIndexer struct {
+	config     BulkIndexerConfig
+	compressor Compressor
+
+	items                    []*bufferedItem
+	uncompressedLen          int
+	bytesUncompressedFlushed int
+
+	writer io.WriteCloser
+
+	capabilitiesResolved bool
+	capabilities         esclient.Capabilities
+	clusterVersion       string
+
+	flushAttempt int
+}
+
+// NewBulkIndexer creates a new BulkIndexer using the given config.
+func NewBulkIndexer(config BulkIndexerConfig) (*BulkIndexer, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("docappender: Client must be set")
+	}
+	compressor := config.Compressor
+	if compressor == nil {
+		compressor = &GzipCompressor{Level: config.CompressionLevel}
+	}
+	return &BulkIndexer{config: config, compressor: compressor}, nil
+}
+
+// Add encodes item.Body and appends it to the in-memory buffer, to be
+// flushed on the next call to Flush.
+func (b *BulkIndexer) Add(item BulkIndexerItem) error {
+	var doc bytes.Buffer
+	if _, err := item.Body.WriteTo(&doc); err != nil {
+		return fmt.Errorf("docappender: failed to encode document: %w", err)
+	}
+
+	meta := map[string]any{"_index": item.Index}
+	if item.DocumentID != "" {
+		meta["_id"] = item.DocumentID
+	}
+	if item.RequireAlias {
+		meta["require_alias"] = true
+	}
+	if len(item.DynamicTemplates) > 0 {
+		meta["dynamic_templates"] = item.DynamicTemplates
+	}
+	action, err := json.Marshal(map[string]any{"create": meta})
+	if err != nil {
+		return fmt.Errorf("docappender: failed to encode action: %w", err)
+	}
+	action = append(action, '\n')
+
+	buffered := &bufferedItem{
+		item:     item,
+		action:   action,
+		document: append(doc.Bytes(), '\n'),
+	}
+	b.items = append(b.items, buffered)
+	b.uncompressedLen += buffered.size()
+	return nil
+}
+
+// ClusterVersion returns the Elasticsearch version.number reported by
+// the most recent ping of the cluster, or "" if ClusterInfoProvider is
+// unset or has not yet been queried (i.e. before the first Flush call).
+func (b *BulkIndexer) ClusterVersion() string {
+	return b.clusterVersion
+}
+
+func (b *BulkIndexer) resolveCapabilities(ctx context.Context) error {
+	if b.capabilitiesResolved {
+		return nil
+	}
+	if b.config.ClusterInfoProvider == nil {
+		b.capabilities = esclient.CapabilitiesForVersion("")
+		b.capabilitiesResolved = true
+		return nil
+	}
+	info, err := b.config.ClusterInfoProvider.ClusterInfo(ctx)
+	if err != nil {
+		return fmt.Errorf("docappender: failed to determine cluster capabilities: %w", err)
+	}
+	b.clusterVersion = info.Version.Number
+	b.capabilities = esclient.CapabilitiesForClusterInfo(info)
+	b.capabilitiesResolved = true
+	return nil
+}
+
+// Items returns the number of buffered documents awaiting a Flush call.
+func (b *BulkIndexer) Items() int {
+	return len(b.items)
+}
+
+// Len returns the number of buffered documents awaiting a Flush call.
+//
+// Len is equivalent to Items, and is retained for backwards compatibility.
+func (b *BulkIndexer) Len() int {
+	return len(b.items)
+}
+
+// UncompressedLen returns the total uncompressed size, in bytes, of the
+// documents currently buffered.
+func (b *BulkIndexer) UncompressedLen() int {
+	return b.uncompressedLen
+}
+
+// BytesUncompressedFlushed returns the uncompressed size, in bytes, of
+// the documents included in the request body of the most recent Flush
+// call that received a valid bulk response. Flush calls that send no
+// request, or fail before such a response, leave it unchanged.
+func (b *BulkIndexer) BytesUncompressedFlushed() int {
+	return b.bytesUncompressedFlushed
+}
+
+// Flush sends the buffered documents to Elasticsearch's bulk API.
+// Documents that fail with a retryable status are kept buffered, to be
+// resent on a future Flush call once their backoff delay has elapsed.
+func (b *BulkIndexer) Flush(ctx context.Context) (stat BulkIndexerResponseStat, err error) {
+	if len(b.items) == 0 {
+		return stat, nil
+	}
+	if err = b.resolveCapabilities(ctx); err != nil {
+		return stat, err
+	}
+
+	now := time.Now()
+	eligible := make([]*bufferedItem, 0, len(b.items))
+	deferred := make([]*bufferedItem, 0)
+	for _, buffered := range b.items {
+		if !buffered.nextAttempt.IsZero() && buffered.nextAttempt.After(now) {
+			deferred = append(deferred, buffered)
+			continue
+		}
+		eligible = append(eligible, buffered)
+	}
+	stat.DeferredDocs = int64(len(deferred))
+	if len(eligible) == 0 {
+		return stat, nil
+	}
+
+	if b.config.BeforeFlush != nil {
+		items := make([]BulkIndexerItem, len(eligible))
+		for i, buffered := range eligible {
+			items[i] = buffered.item
+		}
+		b.config.BeforeFlush(ctx, items)
+	}
+
+	reqInfo := BulkRequestInfo{ItemCount: len(eligible)}
+	var respInfo BulkResponseInfo
+	if b.config.AfterFlush != nil {
+		defer func() {
+			b.config.AfterFlush(ctx, reqInfo, respInfo, err)
+		}()
+	}
+
+	var buf bytes.Buffer
+	if b.writer == nil {
+		b.writer = b.compressor.NewWriter(&buf)
+	} else {
+		b.compressor.Reset(&buf)
+	}
+	for _, buffered := range eligible {
+		b.writer.Write(buffered.wireAction(b.capabilities))
+		b.writer.Write(buffered.document)
+		reqInfo.UncompressedSize += buffered.size()
+	}
+	if err = b.writer.Close(); err != nil {
+		return stat, fmt.Errorf("docappender: failed to close compressor: %w", err)
+	}
+	reqInfo.CompressedSize = buf.Len()
+	b.flushAttempt++
+	reqInfo.Attempt = b.flushAttempt
+
+	req, err2 := http.NewRequestWithContext(ctx, http.MethodPost, "/_bulk", &buf)
+	if err2 != nil {
+		err = fmt.Errorf("docappender: failed to create bulk request: %w", err2)
+		return stat, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if encoding := b.compressor.ContentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err2 := b.config.Client.Perform(req)
+	if err2 != nil {
+		err = fmt.Errorf("docappender: failed to execute bulk request: %w", err2)
+		return stat, err
+	}
+	defer resp.Body.Close()
+	respInfo.StatusCode = resp.StatusCode
+
+	var result struct {
+		Took  int64                                `json:"took"`
+		Items []map[string]BulkIndexerResponseItem `json:"items"`
+	}
+
+	if err2 := json.NewDecoder(resp.Body).Decode(&result); err2 != nil {
+		err = fmt.Errorf("docappender: failed to decode bulk response: %w", err2)
+		return stat, err
+	}
+	if len(result.Items) != len(eligible) {
+		err = fmt.Errorf("docappender: bulk response item count (%d) does not match request item count (%d)", len(result.Items), len(eligible))
+		return stat, err
+	}
+	respInfo.TookMillis = result.Took
+
+	retryAfter, hasRetryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	b.bytesUncompressedFlushed = reqInfo.UncompressedSize
+	remaining := deferred
+	var sinkErrs []error
+	statusCounts := make(map[int]int)
+	for i, itemsMap := range result.Items {
+		buffered := eligible[i]
+		var result BulkIndexerResponseItem
+		for _, v := range itemsMap {
+			result = v
+			break
+		}
+		statusCounts[result.Status]++
+
+		if !isFailureStatus(result.Status) {
+			stat.Indexed++
+			if b.config.OnItemResult != nil {
+				b.config.OnItemResult(ctx, buffered.item, result)
+			}
+			continue
+		}
+
+		retryable := b.isRetryableStatus(result.Status) && buffered.attempts < b.config.MaxDocumentRetries
+		if !retryable && !b.config.CaptureFullErrorReason {
+			result.Error.Reason = redactErrorReason(result.Error.Reason)
+		}
+		stat.FailedDocs = append(stat.FailedDocs, result)
+
+		if retryable {
+			buffered.attempts++
+			delay := retryAfter
+			if !hasRetryAfter {
+				delay = b.retryBackoff(buffered.attempts)
+			}
+			buffered.nextAttempt = now.Add(delay)
+			remaining = append(remaining, buffered)
+			stat.RetriedDocs++
+			if b.config.OnItemResult != nil {
+				b.config.OnItemResult(ctx, buffered.item, result)
+			}
+			continue
+		}
+
+		stat.PermanentlyDroppedDocs++
+		if b.config.FailureSink != nil {
+			if sinkErr := b.config.FailureSink.OnDocumentFailed(ctx, buffered.discardedItem(), result); sinkErr != nil {
+				sinkErrs = append(sinkErrs, fmt.Errorf("docappender: failure sink returned an error for document in index %q: %w", buffered.item.Index, sinkErr))
+			}
+		}
+		if b.config.OnItemResult != nil {
+			b.config.OnItemResult(ctx, buffered.item, result)
+		}
+	}
+	respInfo.StatusCounts = statusCounts
+
+	b.items = remaining
+	b.uncompressedLen = 0
+	for _, buffered := range b.items {
+		b.uncompressedLen += buffered.size()
+	}
+	err = errors.Join(sinkErrs...)
+	return stat, err
+}
+
+func (b *BulkIndexer) retryBackoff(attempt int) time.Duration {
+	if b.config.RetryBackoff == nil {
+		return 0
+	}
+	return b.config.RetryBackoff(attempt)
+}
+
+func (b *BulkIndexer) isRetryableStatus(status int) bool {
+	for _, s := range b.config.RetryOnDocumentStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func isFailureStatus(status int) bool {
+	return status < 200 || status > 299
+}
+
+// redactErrorReason truncates reason at the first ". " separator, to
+// avoid leaking potentially sensitive field values included by
+// Elasticsearch in some mapping errors.
+func redactErrorReason(reason string) string {
+	if i := indexOf(reason, ". "); i >= 0 {
+		return reason[:i]
+	}
+	return reason
+}
+
+func indexOf(s, sep string) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if s[i:i+len(sep)] == sep {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
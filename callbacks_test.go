@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package docappender_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2"
+	"github.com/elastic/go-docappender/v2/docappendertest"
+)
+
+func TestBulkIndexerCallbacks(t *testing.T) {
+	client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, result := docappendertest.DecodeBulkRequest(r)
+		for _, itemsMap := range result.Items {
+			for k, item := range itemsMap {
+				result.HasErrors = true
+				item.Status = http.StatusInternalServerError
+				itemsMap[k] = item
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+
+	var beforeFlushItems []docappender.BulkIndexerItem
+	var afterFlushReq docappender.BulkRequestInfo
+	var afterFlushResp docappender.BulkResponseInfo
+	var afterFlushErr error
+	var afterFlushCalls int
+	var itemResults []docappender.BulkIndexerResponseItem
+
+	indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{
+		Client: client,
+		BeforeFlush: func(ctx context.Context, items []docappender.BulkIndexerItem) {
+			beforeFlushItems = items
+		},
+		AfterFlush: func(ctx context.Context, req docappender.BulkRequestInfo, resp docappender.BulkResponseInfo, err error) {
+			afterFlushCalls++
+			afterFlushReq = req
+			afterFlushResp = resp
+			afterFlushErr = err
+		},
+		OnItemResult: func(ctx context.Context, item docappender.BulkIndexerItem, resp docappender.BulkIndexerResponseItem) {
+			itemResults = append(itemResults, resp)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(docappender.BulkIndexerItem{
+		Index: "testidx",
+		Body:  newJSONReader(map[string]any{"@timestamp": time.Now().Format(docappendertest.TimestampFormat)}),
+	}))
+
+	stat, err := indexer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stat.PermanentlyDroppedDocs)
+
+	require.Len(t, beforeFlushItems, 1)
+	require.Equal(t, "testidx", beforeFlushItems[0].Index)
+
+	require.Equal(t, 1, afterFlushCalls)
+	require.Equal(t, 1, afterFlushReq.ItemCount)
+	require.Equal(t, 1, afterFlushReq.Attempt)
+	require.Equal(t, http.StatusOK, afterFlushResp.StatusCode)
+	require.NoError(t, afterFlushErr)
+
+	require.Len(t, itemResults, 1)
+	require.Equal(t, http.StatusInternalServerError, itemResults[0].Status)
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package docappender_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2"
+	"github.com/elastic/go-docappender/v2/docappendertest"
+	"github.com/elastic/go-docappender/v2/esclient"
+)
+
+type stubClusterInfoProvider struct {
+	info esclient.ClusterInfo
+}
+
+func (s stubClusterInfoProvider) ClusterInfo(ctx context.Context) (esclient.ClusterInfo, error) {
+	return s.info, nil
+}
+
+func TestBulkIndexerDropsUnsupportedFields(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		provider esclient.ClusterInfoProvider
+	}{
+		{
+			name:     "serverless",
+			provider: stubClusterInfoProvider{info: clusterInfo("", "serverless")},
+		},
+		{
+			name:     "es6",
+			provider: stubClusterInfoProvider{info: clusterInfo("6.8.23", "")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var request docappendertest.BulkRequest
+			client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+				var result docappendertest.BulkResponse
+				request, result = docappendertest.DecodeBulkRequest(r)
+				json.NewEncoder(w).Encode(result)
+			})
+			indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{
+				Client:              client,
+				ClusterInfoProvider: tc.provider,
+			})
+			require.NoError(t, err)
+			require.NoError(t, indexer.Add(docappender.BulkIndexerItem{
+				Index:            "testidx",
+				RequireAlias:     true,
+				DynamicTemplates: map[string]string{"my_field": "keyword"},
+				Body:             newJSONReader(map[string]any{"@timestamp": time.Now().Format(docappendertest.TimestampFormat)}),
+			}))
+
+			stat, err := indexer.Flush(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, int64(1), stat.Indexed)
+
+			require.Len(t, request.Actions, 1)
+			create, ok := request.Actions[0]["create"].(map[string]any)
+			require.True(t, ok)
+			require.NotContains(t, create, "require_alias")
+			require.NotContains(t, create, "dynamic_templates")
+		})
+	}
+}
+
+func clusterInfo(version, buildFlavor string) esclient.ClusterInfo {
+	var info esclient.ClusterInfo
+	info.Version.Number = version
+	info.Version.BuildFlavor = buildFlavor
+	return info
+}
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package docappender_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2"
+	"github.com/elastic/go-docappender/v2/docappendertest"
+)
+
+func TestNoopCompressor(t *testing.T) {
+	var compressor docappender.NoopCompressor
+	var buf bytes.Buffer
+
+	w := compressor.NewWriter(&buf)
+	require.Equal(t, "", compressor.ContentEncoding())
+
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, "hello", buf.String())
+
+	var buf2 bytes.Buffer
+	compressor.Reset(&buf2)
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	require.Equal(t, "world", buf2.String())
+}
+
+func TestZstdCompressor(t *testing.T) {
+	var compressor docappender.ZstdCompressor
+	require.Equal(t, "zstd", compressor.ContentEncoding())
+
+	var buf bytes.Buffer
+	w := compressor.NewWriter(&buf)
+	_, err := w.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NotEqual(t, "hello world", buf.String(), "expected compressed output to differ from input")
+
+	var buf2 bytes.Buffer
+	compressor.Reset(&buf2)
+	_, err = w.Write([]byte("hello again"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Greater(t, buf2.Len(), 0)
+}
+
+func TestBulkIndexerZstdContentEncoding(t *testing.T) {
+	var gotContentEncoding string
+	client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotContentEncoding = r.Header.Get("Content-Encoding")
+		_, result := docappendertest.DecodeBulkRequest(r)
+		json.NewEncoder(w).Encode(result)
+	})
+	indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{
+		Client:     client,
+		Compressor: &docappender.ZstdCompressor{},
+	})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Add(docappender.BulkIndexerItem{
+		Index: "testidx",
+		Body:  newJSONReader(map[string]any{"@timestamp": time.Now().Format(docappendertest.TimestampFormat)}),
+	}))
+
+	stat, err := indexer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stat.Indexed)
+	require.Equal(t, "zstd", gotContentEncoding)
+}
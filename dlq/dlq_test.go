@@ -0,0 +1,81 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dlq_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2"
+	"github.com/elastic/go-docappender/v2/dlq"
+	"github.com/elastic/go-docappender/v2/docappendertest"
+)
+
+func TestNewRingSinkInvalidCapacity(t *testing.T) {
+	_, err := dlq.NewRingSink(0)
+	require.Error(t, err)
+}
+
+func TestRingSinkWrapsAtCapacity(t *testing.T) {
+	sink, err := dlq.NewRingSink(2)
+	require.NoError(t, err)
+	for i, id := range []string{"a", "b", "c"} {
+		err := sink.OnDocumentFailed(context.Background(), docappender.BulkIndexerItem{
+			Index:      "testidx",
+			DocumentID: id,
+			Body:       bytes.NewReader([]byte(`{}`)),
+		}, docappender.BulkIndexerResponseItem{Status: http.StatusBadRequest})
+		require.NoError(t, err, i)
+	}
+
+	entries := sink.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "b", entries[0].DocumentID)
+	require.Equal(t, "c", entries[1].DocumentID)
+}
+
+func TestFileSinkReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+	sink, err := dlq.NewFileSink(path)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.OnDocumentFailed(context.Background(), docappender.BulkIndexerItem{
+		Index: "testidx",
+		Body:  bytes.NewReader([]byte(`{"@timestamp":"2024-01-01T00:00:00.000Z"}`)),
+	}, docappender.BulkIndexerResponseItem{Status: http.StatusBadRequest}))
+	require.NoError(t, sink.Close())
+
+	client := docappendertest.NewMockElasticsearchClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	indexer, err := docappender.NewBulkIndexer(docappender.BulkIndexerConfig{Client: client})
+	require.NoError(t, err)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	n, err := dlq.Replay(context.Background(), f, indexer)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, 1, indexer.Items())
+}
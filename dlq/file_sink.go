@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dlq provides docappender.FailureSink implementations for
+// documents that a BulkIndexer has permanently given up on.
+package dlq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/elastic/go-docappender/v2"
+)
+
+// entry is the NDJSON record written to a FileSink for each failed
+// document, and read back by Replay.
+type entry struct {
+	Index            string                              `json:"index"`
+	DocumentID       string                              `json:"document_id,omitempty"`
+	RequireAlias     bool                                `json:"require_alias,omitempty"`
+	DynamicTemplates map[string]string                   `json:"dynamic_templates,omitempty"`
+	Document         json.RawMessage                     `json:"document"`
+	Error            docappender.BulkIndexerResponseItem `json:"error"`
+}
+
+// FileSink is a docappender.FailureSink that appends every permanently
+// failed document, along with the error Elasticsearch reported for it,
+// to an NDJSON file as a simple dead-letter queue.
+//
+// FileSink is safe for concurrent use.
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileSink opens (creating if necessary) the file at path for
+// appending, and returns a FileSink that writes failed documents to it.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("dlq: failed to open %q: %w", path, err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// OnDocumentFailed implements docappender.FailureSink.
+func (s *FileSink) OnDocumentFailed(ctx context.Context, item docappender.BulkIndexerItem, lastResponse docappender.BulkIndexerResponseItem) error {
+	var doc bytes.Buffer
+	if _, err := item.Body.WriteTo(&doc); err != nil {
+		return fmt.Errorf("dlq: failed to read document body: %w", err)
+	}
+	line, err := json.Marshal(entry{
+		Index:            item.Index,
+		DocumentID:       item.DocumentID,
+		RequireAlias:     item.RequireAlias,
+		DynamicTemplates: item.DynamicTemplates,
+		Document:         doc.Bytes(),
+		Error:            lastResponse,
+	})
+	if err != nil {
+		return fmt.Errorf("dlq: failed to encode entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(line)
+	return err
+}
+
+// Replay reads the NDJSON entries written by a FileSink from r and re-adds each one to indexer, so that they can be retried
+// on the next Flush. It returns the number of documents replayed.
+func Replay(ctx context.Context, r io.Reader, indexer *docappender.BulkIndexer) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var n int
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return n, fmt.Errorf("dlq: failed to decode entry: %w", err)
+		}
+		err := indexer.Add(docappender.BulkIndexerItem{
+			Index:            e.Index,
+			DocumentID:       e.DocumentID,
+			RequireAlias:     e.RequireAlias,
+			DynamicTemplates: e.DynamicTemplates,
+			Body:             bytes.NewReader(e.Document),
+		})
+		if err != nil {
+			return n, fmt.Errorf("dlq: failed to re-add document: %w", err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("dlq: failed to read entries: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dlq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/elastic/go-docappender/v2"
+)
+
+// RingSinkEntry records a single document handed to a RingSink, along
+// with the error Elasticsearch last reported for it.
+type RingSinkEntry struct {
+	Index            string
+	DocumentID       string
+	RequireAlias     bool
+	DynamicTemplates map[string]string
+	Document         []byte
+	Error            docappender.BulkIndexerResponseItem
+}
+
+// RingSink is an in-memory docappender.FailureSink of fixed capacity,
+// intended for use in tests: once full, it discards the oldest entry to
+// make room for the newest.
+//
+// RingSink is safe for concurrent use.
+type RingSink struct {
+	mu       sync.Mutex
+	entries  []RingSinkEntry
+	capacity int
+	next     int
+	full     bool
+}
+
+// NewRingSink returns a RingSink that retains at most capacity entries.
+func NewRingSink(capacity int) (*RingSink, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("dlq: capacity must be positive, got %d", capacity)
+	}
+	return &RingSink{capacity: capacity}, nil
+}
+
+// OnDocumentFailed implements docappender.FailureSink.
+func (s *RingSink) OnDocumentFailed(ctx context.Context, item docappender.BulkIndexerItem, lastResponse docappender.BulkIndexerResponseItem) error {
+	var doc bytes.Buffer
+	if _, err := item.Body.WriteTo(&doc); err != nil {
+		return fmt.Errorf("dlq: failed to read document body: %w", err)
+	}
+	entry := RingSinkEntry{
+		Index:            item.Index,
+		DocumentID:       item.DocumentID,
+		RequireAlias:     item.RequireAlias,
+		DynamicTemplates: item.DynamicTemplates,
+		Document:         doc.Bytes(),
+		Error:            lastResponse,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < s.capacity {
+		s.entries = append(s.entries, entry)
+	} else {
+		s.entries[s.next] = entry
+		s.full = true
+	}
+	s.next = (s.next + 1) % s.capacity
+	return nil
+}
+
+// Entries returns the entries currently retained, oldest first.
+func (s *RingSink) Entries() []RingSinkEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.full {
+		out := make([]RingSinkEntry, len(s.entries))
+		copy(out, s.entries)
+		return out
+	}
+	out := make([]RingSinkEntry, 0, s.capacity)
+	out = append(out, s.entries[s.next:]...)
+	out = append(out, s.entries[:s.next]...)
+	return out
+}
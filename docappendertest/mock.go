@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package docappendertest provides helpers for testing code that uses
+// the docappender package, without requiring a real Elasticsearch
+// cluster.
+package docappendertest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/elastic/go-docappender/v2"
+)
+
+// TimestampFormat is the format used to encode @timestamp fields in
+// documents generated by tests.
+const TimestampFormat = "2006-01-02T15:04:05.000Z"
+
+// BulkRequest holds the decoded action/document pairs of a bulk request,
+// in the order they were sent.
+type BulkRequest struct {
+	Actions   []map[string]any
+	Documents []map[string]any
+}
+
+// BulkResponse is a round-trippable representation of an Elasticsearch
+// bulk API response, suitable for mutating in test handlers before
+// encoding it back to the client.
+type BulkResponse struct {
+	Took      int                                              `json:"took"`
+	HasErrors bool                                             `json:"errors"`
+	Items     []map[string]docappender.BulkIndexerResponseItem `json:"items"`
+}
+
+// NewMockElasticsearchClient returns a docappender.Transport backed by
+// an httptest.Server that invokes handler for every bulk request.
+// The server is closed automatically when the test completes.
+func NewMockElasticsearchClient(t testing.TB, handler http.HandlerFunc) docappender.Transport {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &mockTransport{srv: srv}
+}
+
+type mockTransport struct {
+	srv *httptest.Server
+}
+
+func (m *mockTransport) Perform(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = "http"
+	req.URL.Host = m.srv.Listener.Addr().String()
+	return http.DefaultClient.Do(req)
+}
+
+// DecodeBulkRequest decodes the NDJSON body of r, transparently
+// decompressing it according to its Content-Encoding, and returns the
+// decoded request along with a BulkResponse reporting every action as
+// having succeeded with status 200. Callers typically mutate the
+// returned BulkResponse to simulate failures before encoding it back to
+// the client.
+func DecodeBulkRequest(r *http.Request) (BulkRequest, BulkResponse) {
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gzr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		defer gzr.Close()
+		body = gzr
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var request BulkRequest
+	var response BulkResponse
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var action map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			panic(err)
+		}
+		request.Actions = append(request.Actions, action)
+
+		if !scanner.Scan() {
+			panic("docappendertest: missing document line for action")
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			panic(err)
+		}
+		request.Documents = append(request.Documents, doc)
+
+		items := make(map[string]docappender.BulkIndexerResponseItem)
+		for actionType, meta := range action {
+			var item docappender.BulkIndexerResponseItem
+			if m, ok := meta.(map[string]any); ok {
+				if index, ok := m["_index"].(string); ok {
+					item.Index = index
+				}
+			}
+			item.Status = http.StatusOK
+			items[actionType] = item
+		}
+		response.Items = append(response.Items, items)
+	}
+	if err := scanner.Err(); err != nil {
+		panic(err)
+	}
+	return request, response
+}
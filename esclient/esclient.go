@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package esclient holds the transport concerns that depend on which
+// Elasticsearch version a BulkIndexer is talking to: pinging the
+// cluster, and turning the result into the set of bulk-encoding
+// Capabilities the caller should use.
+package esclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Transport is the minimal interface esclient needs of an Elasticsearch
+// client in order to ping the cluster.
+type Transport interface {
+	Perform(*http.Request) (*http.Response, error)
+}
+
+// ClusterInfo holds the subset of Elasticsearch's root endpoint
+// response (GET /) that bulk-encoding decisions depend on.
+type ClusterInfo struct {
+	ClusterName string `json:"cluster_name"`
+	Version     struct {
+		Number string `json:"number"`
+
+		// BuildFlavor is "serverless" for Elasticsearch Serverless
+		// projects, and "default" otherwise.
+		BuildFlavor string `json:"build_flavor"`
+	} `json:"version"`
+}
+
+// IsServerless reports whether info describes an Elasticsearch
+// Serverless project.
+func (info ClusterInfo) IsServerless() bool {
+	return info.Version.BuildFlavor == "serverless"
+}
+
+// ClusterInfoProvider returns information about the Elasticsearch
+// cluster a BulkIndexer is sending requests to.
+type ClusterInfoProvider interface {
+	ClusterInfo(ctx context.Context) (ClusterInfo, error)
+}
+
+// Pinger is a ClusterInfoProvider that issues a single `GET /` request
+// against Transport, on first use, and caches the result for its
+// lifetime.
+type Pinger struct {
+	Transport Transport
+
+	info    ClusterInfo
+	fetched bool
+}
+
+// NewPinger returns a Pinger that pings the cluster through transport.
+func NewPinger(transport Transport) *Pinger {
+	return &Pinger{Transport: transport}
+}
+
+// ClusterInfo implements ClusterInfoProvider.
+func (p *Pinger) ClusterInfo(ctx context.Context) (ClusterInfo, error) {
+	if p.fetched {
+		return p.info, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return ClusterInfo{}, fmt.Errorf("esclient: failed to create ping request: %w", err)
+	}
+	resp, err := p.Transport.Perform(req)
+	if err != nil {
+		return ClusterInfo{}, fmt.Errorf("esclient: failed to ping cluster: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var info ClusterInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return ClusterInfo{}, fmt.Errorf("esclient: failed to decode cluster info: %w", err)
+	}
+	p.info = info
+	p.fetched = true
+	return info, nil
+}
+
+// Capabilities describes how a bulk action line should be rendered for
+// a given Elasticsearch version.
+type Capabilities struct {
+	// RequiresType reports whether the action line must include
+	// "_type", as required by Elasticsearch 6.x.
+	RequiresType bool
+
+	// SupportsRequireAlias reports whether the action line may include
+	// "require_alias", as supported from Elasticsearch 7.x onwards.
+	SupportsRequireAlias bool
+
+	// SupportsDynamicTemplates reports whether the action line may
+	// include "dynamic_templates", as supported from Elasticsearch 7.x
+	// onwards.
+	SupportsDynamicTemplates bool
+}
+
+// modernCapabilities is used for Elasticsearch 7.x/8.x, and as the
+// default when a cluster's version cannot be determined.
+var modernCapabilities = Capabilities{
+	SupportsRequireAlias:     true,
+	SupportsDynamicTemplates: true,
+}
+
+// serverlessCapabilities is used for Elasticsearch Serverless, which
+// restricts "require_alias" and "dynamic_templates" in bulk action
+// lines.
+var serverlessCapabilities = Capabilities{}
+
+// CapabilitiesForClusterInfo returns the Capabilities appropriate for
+// the cluster described by info, taking its build flavor into account
+// in addition to its version, so that Elasticsearch Serverless (which
+// does not report a conventional version.number) is handled correctly.
+func CapabilitiesForClusterInfo(info ClusterInfo) Capabilities {
+	if info.IsServerless() {
+		return serverlessCapabilities
+	}
+	return CapabilitiesForVersion(info.Version.Number)
+}
+
+// CapabilitiesForVersion returns the Capabilities appropriate for the
+// given Elasticsearch version.number (e.g. "7.17.0"). An empty or
+// unparseable version is treated like the latest supported major
+// version, so that callers which do not configure a
+// ClusterInfoProvider keep today's one-size-fits-all rendering.
+//
+// CapabilitiesForVersion cannot detect Elasticsearch Serverless; use
+// CapabilitiesForClusterInfo when a ClusterInfo is available.
+func CapabilitiesForVersion(version string) Capabilities {
+	if major, ok := majorVersion(version); ok && major == 6 {
+		return Capabilities{RequiresType: true}
+	}
+	return modernCapabilities
+}
+
+func majorVersion(version string) (int, bool) {
+	major, _, ok := strings.Cut(version, ".")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package esclient_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/go-docappender/v2/esclient"
+)
+
+func TestCapabilitiesForVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    esclient.Capabilities
+	}{
+		{
+			name:    "es6",
+			version: "6.8.23",
+			want:    esclient.Capabilities{RequiresType: true},
+		},
+		{
+			name:    "es7",
+			version: "7.17.0",
+			want:    esclient.Capabilities{SupportsRequireAlias: true, SupportsDynamicTemplates: true},
+		},
+		{
+			name:    "es8",
+			version: "8.13.0",
+			want:    esclient.Capabilities{SupportsRequireAlias: true, SupportsDynamicTemplates: true},
+		},
+		{
+			name:    "unknown defaults to modern",
+			version: "",
+			want:    esclient.Capabilities{SupportsRequireAlias: true, SupportsDynamicTemplates: true},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, esclient.CapabilitiesForVersion(tc.version))
+		})
+	}
+}
+
+func TestCapabilitiesForClusterInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		info esclient.ClusterInfo
+		want esclient.Capabilities
+	}{
+		{
+			name: "es8",
+			info: clusterInfo("8.13.0", ""),
+			want: esclient.Capabilities{SupportsRequireAlias: true, SupportsDynamicTemplates: true},
+		},
+		{
+			name: "es6",
+			info: clusterInfo("6.8.23", ""),
+			want: esclient.Capabilities{RequiresType: true},
+		},
+		{
+			name: "serverless",
+			info: clusterInfo("", "serverless"),
+			want: esclient.Capabilities{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, esclient.CapabilitiesForClusterInfo(tc.info))
+		})
+	}
+}
+
+func clusterInfo(version, buildFlavor string) esclient.ClusterInfo {
+	var info esclient.ClusterInfo
+	info.Version.Number = version
+	info.Version.BuildFlavor = buildFlavor
+	return info
+}